@@ -17,9 +17,9 @@ package consumer
 import (
 	"encoding/json"
 	"fmt"
+	"time"
 
 	"google.golang.org/protobuf/encoding/protojson"
-	"google.golang.org/protobuf/types/known/structpb"
 
 	"mosn.io/htnn/pkg/filtermanager/api"
 	"mosn.io/htnn/pkg/filtermanager/model"
@@ -27,12 +27,7 @@ import (
 	"mosn.io/htnn/pkg/plugins"
 )
 
-var (
-	logger = log.DefaultLogger.WithName("consumer")
-
-	resourceIndex = make(map[string]map[string]*Consumer)
-	scopeIndex    map[string]map[string]map[string]*Consumer
-)
+var logger = log.DefaultLogger.WithName("consumer")
 
 type Consumer struct {
 	// We provide `Name()` as the API for the `api.Consumer` interface.
@@ -65,20 +60,11 @@ func (c *Consumer) InitConfigs() error {
 
 	c.ConsumerConfigs = make(map[string]api.PluginConsumerConfig, len(c.Auth))
 	for name, data := range c.Auth {
-		p, ok := plugins.LoadHttpPlugin(name).(plugins.ConsumerPlugin)
-		if !ok {
-			return fmt.Errorf("plugin %s is not for consumer", name)
-		}
-
-		conf := p.ConsumerConfig()
-		err := protojson.Unmarshal([]byte(data), conf)
-		if err != nil {
-			return fmt.Errorf("failed to unmarshal consumer config for plugin %s: %w", name, err)
-		}
-
-		err = conf.Validate()
+		start := time.Now()
+		conf, err := initPluginConsumerConfig(name, data)
+		metrics.initConfigLatency.WithLabelValues(name).Observe(time.Since(start).Seconds())
 		if err != nil {
-			return fmt.Errorf("failed to validate consumer config for plugin %s: %w", name, err)
+			return err
 		}
 
 		c.ConsumerConfigs[name] = conf
@@ -106,85 +92,26 @@ func (c *Consumer) InitConfigs() error {
 	return nil
 }
 
-func updateConsumers(value *structpb.Struct) {
-	// build the idx for syncing with the control plane
-	for ns, nsValue := range value.GetFields() {
-		currIdx := resourceIndex[ns]
-		if currIdx == nil {
-			currIdx = make(map[string]*Consumer)
-		}
-
-		newIdx := map[string]*Consumer{}
-		for name, value := range nsValue.GetStructValue().GetFields() {
-			fields := value.GetStructValue().GetFields()
-			v := fields["v"].GetStringValue()
-
-			currValue, ok := currIdx[name]
-			if !ok || currValue.resourceVersion != v {
-				s := fields["d"].GetStringValue()
-				var c Consumer
-				err := c.Unmarshal(s)
-				if err != nil {
-					logger.Error(err, "failed to unmarshal", "consumer", s, "name", name, "namespace", ns)
-					continue
-				}
-
-				c.namespace = ns
-
-				err = c.InitConfigs()
-				if err != nil {
-					logger.Error(err, "failed to init", "consumer", s, "name", name, "namespace", ns)
-					continue
-				}
-
-				c.resourceVersion = v
-				newIdx[name] = &c
-			} else {
-				newIdx[name] = currValue
-			}
-		}
-		resourceIndex[ns] = newIdx
+// initPluginConsumerConfig unmarshals and validates the consumer config for a single plugin.
+// It's split out of InitConfigs so the parsing time of each plugin can be timed independently.
+func initPluginConsumerConfig(name, data string) (api.PluginConsumerConfig, error) {
+	p, ok := plugins.LoadHttpPlugin(name).(plugins.ConsumerPlugin)
+	if !ok {
+		return nil, fmt.Errorf("plugin %s is not for consumer", name)
 	}
 
-	// build the idx for matching in the data plane
-	scopeIndex = make(map[string]map[string]map[string]*Consumer)
-	for ns, nsValue := range resourceIndex {
-		nsScopeIdx := make(map[string]map[string]*Consumer)
-		for _, value := range nsValue {
-			for pluginName, cfg := range value.ConsumerConfigs {
-				pluginScopeIdx := nsScopeIdx[pluginName]
-				if pluginScopeIdx == nil {
-					pluginScopeIdx = make(map[string]*Consumer)
-					nsScopeIdx[pluginName] = pluginScopeIdx
-				}
-
-				idx := cfg.Index()
-				if pluginScopeIdx[idx] != nil {
-					// TODO: find an effective way to detect collision in the control plane
-					err := fmt.Errorf("duplicate index %s", value.ConsumerName)
-					logger.Error(err, fmt.Sprintf("ignore consumer %s for plugin %s", pluginName, idx),
-						"namespace", ns, "existing consumer", pluginScopeIdx[idx].ConsumerName)
-					continue
-				}
-				pluginScopeIdx[idx] = value
-			}
-		}
-		scopeIndex[ns] = nsScopeIdx
+	conf := p.ConsumerConfig()
+	err := protojson.Unmarshal([]byte(data), conf)
+	if err != nil {
+		return nil, fmt.Errorf("failed to unmarshal consumer config for plugin %s: %w", name, err)
 	}
-}
 
-// LookupConsumer returns the consumer config for the given namespace, plugin name and key.
-func LookupConsumer(ns, pluginName, key string) (api.Consumer, bool) {
-	if nsIdx, ok := scopeIndex[ns]; ok {
-		if pluginIdx, ok := nsIdx[pluginName]; ok {
-			// return extra bool to indicate whether the key exists so user doesn't need to
-			// distinguish nil interface.
-			// An interface in Go is nil only when both its type and value are nil.
-			c, ok := pluginIdx[key]
-			return c, ok
-		}
+	err = conf.Validate()
+	if err != nil {
+		return nil, fmt.Errorf("failed to validate consumer config for plugin %s: %w", name, err)
 	}
-	return nil, false
+
+	return conf, nil
 }
 
 // Implement pkg.filtermanager.api.Consumer
@@ -0,0 +1,279 @@
+// Copyright The HTNN Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package consumer
+
+import (
+	"fmt"
+	"strings"
+	"sync"
+	"sync/atomic"
+
+	"google.golang.org/protobuf/types/known/structpb"
+
+	"mosn.io/htnn/pkg/filtermanager/api"
+)
+
+// multiKeyPluginConsumerConfig is implemented by consumer plugins that need to be looked up
+// by more than one key (e.g. key+issuer for JWT, or client_id+tenant for OAuth). It's optional:
+// plugins that only implement api.PluginConsumerConfig are indexed by their single Index() key.
+type multiKeyPluginConsumerConfig interface {
+	Indices() []string
+}
+
+// pluginIndexKeys returns every key cfg should be registered under in the scope index,
+// falling back to cfg.Index() when the plugin doesn't implement Indices().
+func pluginIndexKeys(cfg api.PluginConsumerConfig) []string {
+	if mi, ok := cfg.(multiKeyPluginConsumerConfig); ok {
+		if keys := mi.Indices(); len(keys) > 0 {
+			return keys
+		}
+	}
+	return []string{cfg.Index()}
+}
+
+// Index is an immutable, point-in-time view of the consumers known to the data plane.
+// A new Index is built from scratch on every control plane push and swapped in atomically,
+// so readers never observe a partially built index and never need to take a lock.
+type Index struct {
+	// resource indexes consumers by namespace and name, for diffing against the next push.
+	resource map[string]map[string]*Consumer
+	// scope indexes consumers by namespace, plugin name and the plugin-defined key, for
+	// matching in the data plane.
+	scope map[string]map[string]map[string]*Consumer
+}
+
+func emptyIndex() *Index {
+	return &Index{
+		resource: make(map[string]map[string]*Consumer),
+		scope:    make(map[string]map[string]map[string]*Consumer),
+	}
+}
+
+// Consumers returns every consumer known in the given namespace. The order is unspecified.
+func (idx *Index) Consumers(ns string) []api.Consumer {
+	nsIdx := idx.resource[ns]
+	out := make([]api.Consumer, 0, len(nsIdx))
+	for _, c := range nsIdx {
+		out = append(out, c)
+	}
+	return out
+}
+
+// NamespaceStats summarizes a single namespace's view of the index, for diagnostics and
+// admin endpoints.
+type NamespaceStats struct {
+	ConsumerCount int
+	// PluginScopeSizes is the number of indexed keys per plugin, keyed by plugin name.
+	PluginScopeSizes map[string]int
+}
+
+// Stats returns a summary of every namespace in the index, keyed by namespace.
+func (idx *Index) Stats() map[string]NamespaceStats {
+	stats := make(map[string]NamespaceStats, len(idx.resource))
+	for ns, nsIdx := range idx.resource {
+		pluginScopeSizes := make(map[string]int, len(idx.scope[ns]))
+		for pluginName, pluginScopeIdx := range idx.scope[ns] {
+			pluginScopeSizes[pluginName] = len(pluginScopeIdx)
+		}
+		stats[ns] = NamespaceStats{
+			ConsumerCount:    len(nsIdx),
+			PluginScopeSizes: pluginScopeSizes,
+		}
+	}
+	return stats
+}
+
+var currentIndex atomic.Pointer[Index]
+
+func init() {
+	currentIndex.Store(emptyIndex())
+}
+
+// Snapshot returns the current view of the consumer index. It's safe to retain and read
+// from the returned Index after a subsequent update; the Index it points to is never mutated.
+func Snapshot() *Index {
+	return currentIndex.Load()
+}
+
+// SubscribeFunc is called whenever updateConsumers swaps in a new Index, with the previous
+// and the just-installed Index. old is never nil.
+type SubscribeFunc func(old, new *Index)
+
+var (
+	subscribersMu sync.Mutex
+	subscribers   []SubscribeFunc
+)
+
+// Subscribe registers fn to be called after every consumer index update. Subscribers run
+// synchronously on the goroutine that called updateConsumers, so fn should not block.
+func Subscribe(fn SubscribeFunc) {
+	subscribersMu.Lock()
+	defer subscribersMu.Unlock()
+	subscribers = append(subscribers, fn)
+}
+
+func notifySubscribers(old, new *Index) {
+	subscribersMu.Lock()
+	fns := make([]SubscribeFunc, len(subscribers))
+	copy(fns, subscribers)
+	subscribersMu.Unlock()
+
+	for _, fn := range fns {
+		fn(old, new)
+	}
+}
+
+func updateConsumers(value *structpb.Struct) {
+	old := currentIndex.Load()
+	resource := make(map[string]map[string]*Consumer, len(old.resource))
+	consumerCounts := make(map[string]int, len(value.GetFields()))
+
+	// build the idx for syncing with the control plane
+	for ns, nsValue := range value.GetFields() {
+		currIdx := old.resource[ns]
+
+		newIdx := map[string]*Consumer{}
+		for name, value := range nsValue.GetStructValue().GetFields() {
+			fields := value.GetStructValue().GetFields()
+			v := fields["v"].GetStringValue()
+
+			currValue, ok := currIdx[name]
+			if !ok || currValue.resourceVersion != v {
+				s := fields["d"].GetStringValue()
+				var c Consumer
+				err := c.Unmarshal(s)
+				if err != nil {
+					logger.Error(err, "failed to unmarshal", "consumer", s, "name", name, "namespace", ns)
+					metrics.updates.WithLabelValues(ns, resultError).Inc()
+					continue
+				}
+
+				c.namespace = ns
+
+				err = c.InitConfigs()
+				if err != nil {
+					logger.Error(err, "failed to init", "consumer", s, "name", name, "namespace", ns)
+					metrics.updates.WithLabelValues(ns, resultError).Inc()
+					continue
+				}
+
+				c.resourceVersion = v
+				newIdx[name] = &c
+				metrics.updates.WithLabelValues(ns, resultSuccess).Inc()
+			} else {
+				newIdx[name] = currValue
+			}
+		}
+		resource[ns] = newIdx
+		consumerCounts[ns] = len(newIdx)
+	}
+	metrics.reconcileConsumerCounts(consumerCounts)
+
+	// build the idx for matching in the data plane
+	scope := buildScopeIndex(resource)
+
+	pluginScopeSizes := make(map[pluginScopeKey]int)
+	for ns, nsScopeIdx := range scope {
+		for pluginName, pluginScopeIdx := range nsScopeIdx {
+			pluginScopeSizes[pluginScopeKey{namespace: ns, plugin: pluginName}] = len(pluginScopeIdx)
+		}
+	}
+	metrics.reconcilePluginScopeSizes(pluginScopeSizes)
+
+	newIndex := &Index{resource: resource, scope: scope}
+	currentIndex.Store(newIndex)
+	notifySubscribers(old, newIndex)
+}
+
+// buildScopeIndex builds the per-plugin scope index used for matching in the data plane from
+// an already up-to-date resource index, registering every consumer's plugin configs under
+// each of pluginIndexKeys and bumping the duplicate-index counter on collision.
+func buildScopeIndex(resource map[string]map[string]*Consumer) map[string]map[string]map[string]*Consumer {
+	scope := make(map[string]map[string]map[string]*Consumer, len(resource))
+	for ns, nsValue := range resource {
+		nsScopeIdx := make(map[string]map[string]*Consumer)
+		for _, value := range nsValue {
+			for pluginName, cfg := range value.ConsumerConfigs {
+				pluginScopeIdx := nsScopeIdx[pluginName]
+				if pluginScopeIdx == nil {
+					pluginScopeIdx = make(map[string]*Consumer)
+					nsScopeIdx[pluginName] = pluginScopeIdx
+				}
+
+				for _, idx := range pluginIndexKeys(cfg) {
+					if pluginScopeIdx[idx] != nil {
+						// TODO: find an effective way to detect collision in the control plane
+						err := fmt.Errorf("duplicate index %s", idx)
+						logger.Error(err, fmt.Sprintf("ignore consumer %s for plugin %s", value.ConsumerName, pluginName),
+							"namespace", ns, "index", idx, "existing consumer", pluginScopeIdx[idx].ConsumerName)
+						metrics.duplicateIndex.WithLabelValues(ns, pluginName).Inc()
+						continue
+					}
+					pluginScopeIdx[idx] = value
+				}
+			}
+		}
+		scope[ns] = nsScopeIdx
+	}
+	return scope
+}
+
+// LookupConsumer returns the consumer config for the given namespace, plugin name and key.
+func LookupConsumer(ns, pluginName, key string) (api.Consumer, bool) {
+	// return extra bool to indicate whether the key exists so user doesn't need to
+	// distinguish nil interface.
+	// An interface in Go is nil only when both its type and value are nil.
+	return LookupConsumerBy(ns, pluginName, key)
+}
+
+// LookupConsumerBy is like LookupConsumer, but tries each of keys in order and returns the
+// first match. It's for plugins indexed under multiple keys via Indices(), e.g. trying a
+// rotated API key before falling back to the previous one.
+func LookupConsumerBy(ns, pluginName string, keys ...string) (api.Consumer, bool) {
+	idx := currentIndex.Load()
+	pluginIdx, ok := idx.scope[ns][pluginName]
+	if !ok {
+		metrics.lookups.WithLabelValues(ns, pluginName, resultMiss).Inc()
+		return nil, false
+	}
+
+	for _, key := range keys {
+		if c, ok := pluginIdx[key]; ok {
+			metrics.lookups.WithLabelValues(ns, pluginName, resultHit).Inc()
+			return c, true
+		}
+	}
+	metrics.lookups.WithLabelValues(ns, pluginName, resultMiss).Inc()
+	return nil, false
+}
+
+// LookupConsumersByPrefix returns every consumer indexed under a key starting with prefix,
+// for the given namespace and plugin. It's meant for range-scan style lookups such as
+// matching any still-valid key during API-key rotation or scoping to a tenant prefix.
+func LookupConsumersByPrefix(ns, pluginName, prefix string) []api.Consumer {
+	idx := currentIndex.Load()
+	pluginIdx, ok := idx.scope[ns][pluginName]
+	if !ok {
+		return nil
+	}
+
+	var matches []api.Consumer
+	for key, c := range pluginIdx {
+		if strings.HasPrefix(key, prefix) {
+			matches = append(matches, c)
+		}
+	}
+	return matches
+}
@@ -0,0 +1,182 @@
+// Copyright The HTNN Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package consumer
+
+import (
+	"fmt"
+	"sync"
+	"sync/atomic"
+	"testing"
+
+	"google.golang.org/protobuf/types/known/structpb"
+)
+
+// buildConsumersStruct builds the structpb shape used by the control plane to push consumers:
+// { namespace: { name: { "v": resourceVersion, "d": marshaledConsumer } } }.
+func buildConsumersStruct(ns string, n int, version string) *structpb.Struct {
+	names := map[string]interface{}{}
+	for i := 0; i < n; i++ {
+		c := &Consumer{ConsumerName: fmt.Sprintf("consumer-%d", i)}
+		names[c.ConsumerName] = map[string]interface{}{
+			"v": version,
+			"d": c.Marshal(),
+		}
+	}
+
+	s, err := structpb.NewStruct(map[string]interface{}{ns: names})
+	if err != nil {
+		panic(err)
+	}
+	return s
+}
+
+func TestUpdateConsumersAndLookupConsumerConcurrent(t *testing.T) {
+	const ns = "default"
+	const iterations = 2000
+
+	var stop atomic.Bool
+	var readers sync.WaitGroup
+
+	for i := 0; i < 4; i++ {
+		readers.Add(1)
+		go func() {
+			defer readers.Done()
+			for !stop.Load() {
+				LookupConsumer(ns, "some_plugin", "some_key")
+			}
+		}()
+	}
+
+	// updateConsumers is only ever called from a single goroutine at a time (it's driven by
+	// the control plane sync loop), so drive it tightly from the test goroutine itself while
+	// the readers above hammer LookupConsumer concurrently.
+	for i := 0; i < iterations; i++ {
+		updateConsumers(buildConsumersStruct(ns, 4, fmt.Sprintf("v%d", i)))
+	}
+
+	stop.Store(true)
+	readers.Wait()
+}
+
+func TestSnapshotIsImmutableAcrossUpdates(t *testing.T) {
+	const ns = "default"
+
+	updateConsumers(buildConsumersStruct(ns, 2, "v1"))
+	before := Snapshot()
+
+	updateConsumers(buildConsumersStruct(ns, 3, "v2"))
+	after := Snapshot()
+
+	if before == after {
+		t.Fatalf("expected a fresh Index after updateConsumers, got the same pointer")
+	}
+	if len(before.resource[ns]) != 2 {
+		t.Fatalf("expected the earlier snapshot to keep its own view, got %d consumers", len(before.resource[ns]))
+	}
+	if len(after.resource[ns]) != 3 {
+		t.Fatalf("expected the latest snapshot to reflect the new push, got %d consumers", len(after.resource[ns]))
+	}
+}
+
+func TestLookupConsumerByTriesKeysInOrder(t *testing.T) {
+	c1 := &Consumer{ConsumerName: "c1"}
+	c2 := &Consumer{ConsumerName: "c2"}
+	currentIndex.Store(&Index{
+		resource: map[string]map[string]*Consumer{},
+		scope: map[string]map[string]map[string]*Consumer{
+			"ns": {"plugin": {"key-a": c1, "key-b": c2}},
+		},
+	})
+
+	c, ok := LookupConsumerBy("ns", "plugin", "missing", "key-b")
+	if !ok || c.Name() != "c2" {
+		t.Fatalf("expected to fall back to key-b and find c2, got %v, %v", c, ok)
+	}
+
+	c, ok = LookupConsumerBy("ns", "plugin", "key-a", "key-b")
+	if !ok || c.Name() != "c1" {
+		t.Fatalf("expected the first matching key-a to win and find c1, got %v, %v", c, ok)
+	}
+
+	if _, ok := LookupConsumerBy("ns", "plugin", "missing"); ok {
+		t.Fatalf("expected no match for an unknown key")
+	}
+}
+
+func TestLookupConsumersByPrefix(t *testing.T) {
+	c1 := &Consumer{ConsumerName: "c1"}
+	c2 := &Consumer{ConsumerName: "c2"}
+	other := &Consumer{ConsumerName: "other"}
+	currentIndex.Store(&Index{
+		resource: map[string]map[string]*Consumer{},
+		scope: map[string]map[string]map[string]*Consumer{
+			"ns": {"plugin": {
+				"tenant-a:key1": c1,
+				"tenant-a:key2": c2,
+				"tenant-b:key1": other,
+			}},
+		},
+	})
+
+	matches := LookupConsumersByPrefix("ns", "plugin", "tenant-a:")
+	if len(matches) != 2 {
+		t.Fatalf("expected 2 matches for the tenant-a prefix, got %d", len(matches))
+	}
+}
+
+func TestIndexConsumersAndStats(t *testing.T) {
+	const ns = "default"
+
+	updateConsumers(buildConsumersStruct(ns, 3, "v1"))
+	snap := Snapshot()
+
+	consumers := snap.Consumers(ns)
+	if len(consumers) != 3 {
+		t.Fatalf("expected 3 consumers, got %d", len(consumers))
+	}
+
+	stats := snap.Stats()
+	nsStats, ok := stats[ns]
+	if !ok {
+		t.Fatalf("expected stats for namespace %q", ns)
+	}
+	if nsStats.ConsumerCount != 3 {
+		t.Fatalf("expected consumer count 3, got %d", nsStats.ConsumerCount)
+	}
+
+	if len(snap.Consumers("unknown-ns")) != 0 {
+		t.Fatalf("expected no consumers for an unknown namespace")
+	}
+}
+
+func TestSubscribeIsNotifiedOnUpdate(t *testing.T) {
+	const ns = "default"
+
+	var mu sync.Mutex
+	var calls int
+	Subscribe(func(old, new *Index) {
+		mu.Lock()
+		defer mu.Unlock()
+		calls++
+	})
+
+	updateConsumers(buildConsumersStruct(ns, 1, "v1"))
+
+	mu.Lock()
+	defer mu.Unlock()
+	if calls == 0 {
+		t.Fatalf("expected at least one subscriber notification")
+	}
+}
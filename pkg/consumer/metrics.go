@@ -0,0 +1,153 @@
+// Copyright The HTNN Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package consumer
+
+import (
+	"sync"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+const (
+	resultHit  = "hit"
+	resultMiss = "miss"
+
+	resultSuccess = "success"
+	resultError   = "error"
+)
+
+// metrics holds the Prometheus collectors for the consumer package. The collectors are
+// created eagerly so callers can always record against them; RegisterMetrics only controls
+// whether the collectors are exposed to a Prometheus registry.
+var metrics = newConsumerMetrics()
+
+type consumerMetrics struct {
+	lookups           *prometheus.CounterVec
+	updates           *prometheus.CounterVec
+	duplicateIndex    *prometheus.CounterVec
+	consumerCount     *prometheus.GaugeVec
+	pluginScopeSize   *prometheus.GaugeVec
+	initConfigLatency *prometheus.HistogramVec
+
+	// gaugeMu protects knownNamespaces/knownPluginScopes, which track the label sets last
+	// pushed to consumerCount/pluginScopeSize so a namespace or plugin that drops out of a
+	// push can have its gauge removed instead of freezing at its last value.
+	gaugeMu           sync.Mutex
+	knownNamespaces   map[string]struct{}
+	knownPluginScopes map[pluginScopeKey]struct{}
+}
+
+type pluginScopeKey struct {
+	namespace string
+	plugin    string
+}
+
+func newConsumerMetrics() *consumerMetrics {
+	return &consumerMetrics{
+		lookups: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Name: "htnn_consumer_lookups_total",
+			Help: "Total number of consumer lookups, partitioned by namespace, plugin and result (hit/miss)",
+		}, []string{"namespace", "plugin", "result"}),
+
+		updates: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Name: "htnn_consumer_updates_total",
+			Help: "Total number of consumers (re)loaded into the consumer index, partitioned by namespace and result (success/error)",
+		}, []string{"namespace", "result"}),
+
+		duplicateIndex: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Name: "htnn_consumer_duplicate_index_total",
+			Help: "Total number of duplicate index collisions detected while building the consumer scope index",
+		}, []string{"namespace", "plugin"}),
+
+		consumerCount: prometheus.NewGaugeVec(prometheus.GaugeOpts{
+			Name: "htnn_consumer_count",
+			Help: "Current number of consumers known to the data plane, partitioned by namespace",
+		}, []string{"namespace"}),
+
+		pluginScopeSize: prometheus.NewGaugeVec(prometheus.GaugeOpts{
+			Name: "htnn_consumer_plugin_scope_size",
+			Help: "Current number of indexed entries in the per-plugin scope index, partitioned by namespace and plugin",
+		}, []string{"namespace", "plugin"}),
+
+		initConfigLatency: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Name:    "htnn_consumer_init_config_duration_seconds",
+			Help:    "Time spent parsing and validating a consumer's plugin configs in InitConfigs",
+			Buckets: prometheus.DefBuckets,
+		}, []string{"plugin"}),
+
+		knownNamespaces:   make(map[string]struct{}),
+		knownPluginScopes: make(map[pluginScopeKey]struct{}),
+	}
+}
+
+// reconcileConsumerCounts sets htnn_consumer_count to counts and deletes the gauge for any
+// namespace that was reported in a previous call but is absent from counts, so a namespace
+// that's fully depopulated reads as gone rather than freezing at its last value.
+func (m *consumerMetrics) reconcileConsumerCounts(counts map[string]int) {
+	m.gaugeMu.Lock()
+	defer m.gaugeMu.Unlock()
+
+	for ns := range m.knownNamespaces {
+		if _, ok := counts[ns]; !ok {
+			m.consumerCount.DeleteLabelValues(ns)
+		}
+	}
+
+	next := make(map[string]struct{}, len(counts))
+	for ns, n := range counts {
+		m.consumerCount.WithLabelValues(ns).Set(float64(n))
+		next[ns] = struct{}{}
+	}
+	m.knownNamespaces = next
+}
+
+// reconcilePluginScopeSizes is the htnn_consumer_plugin_scope_size analog of
+// reconcileConsumerCounts: it deletes the gauge for any (namespace, plugin) pair that no
+// longer appears, e.g. because the last consumer for that plugin was removed.
+func (m *consumerMetrics) reconcilePluginScopeSizes(sizes map[pluginScopeKey]int) {
+	m.gaugeMu.Lock()
+	defer m.gaugeMu.Unlock()
+
+	for k := range m.knownPluginScopes {
+		if _, ok := sizes[k]; !ok {
+			m.pluginScopeSize.DeleteLabelValues(k.namespace, k.plugin)
+		}
+	}
+
+	next := make(map[pluginScopeKey]struct{}, len(sizes))
+	for k, n := range sizes {
+		m.pluginScopeSize.WithLabelValues(k.namespace, k.plugin).Set(float64(n))
+		next[k] = struct{}{}
+	}
+	m.knownPluginScopes = next
+}
+
+// RegisterMetrics registers the consumer package's Prometheus collectors with reg.
+// It is nil-safe: when reg is nil (e.g. when the package is used outside the data-plane
+// binary), RegisterMetrics is a no-op and the collectors simply keep accumulating in memory.
+func RegisterMetrics(reg prometheus.Registerer) {
+	if reg == nil {
+		return
+	}
+
+	reg.MustRegister(
+		metrics.lookups,
+		metrics.updates,
+		metrics.duplicateIndex,
+		metrics.consumerCount,
+		metrics.pluginScopeSize,
+		metrics.initConfigLatency,
+	)
+}
@@ -0,0 +1,163 @@
+// Copyright The HTNN Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package consumer
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"google.golang.org/protobuf/types/known/structpb"
+
+	"mosn.io/htnn/pkg/filtermanager/api"
+)
+
+// fakeConsumerConfig is a minimal api.PluginConsumerConfig double for exercising the scope
+// index builder without a registered plugin. It embeds *structpb.Struct purely to satisfy
+// the proto.Message part of the interface.
+type fakeConsumerConfig struct {
+	*structpb.Struct
+	key     string
+	indices []string
+}
+
+func (f *fakeConsumerConfig) Index() string     { return f.key }
+func (f *fakeConsumerConfig) Validate() error   { return nil }
+func (f *fakeConsumerConfig) Indices() []string { return f.indices }
+
+func newFakeConsumerConfig(key string) *fakeConsumerConfig {
+	return &fakeConsumerConfig{Struct: &structpb.Struct{}, key: key}
+}
+
+// consumerWithConfig builds a Consumer already populated with a single plugin config, as if
+// InitConfigs had already run, so callers can exercise buildScopeIndex directly.
+func consumerWithConfig(name, plugin string, cfg api.PluginConsumerConfig) *Consumer {
+	return &Consumer{
+		ConsumerName:    name,
+		ConsumerConfigs: map[string]api.PluginConsumerConfig{plugin: cfg},
+	}
+}
+
+func TestRegisterMetricsNilIsNoop(t *testing.T) {
+	RegisterMetrics(nil)
+}
+
+func TestRegisterMetricsRegistersCollectors(t *testing.T) {
+	reg := prometheus.NewRegistry()
+	RegisterMetrics(reg)
+
+	_, err := reg.Gather()
+	if err != nil {
+		t.Fatalf("gather: %v", err)
+	}
+	// A vector with no label values gathers nothing, so check registration directly:
+	// registering the same collector again must report it as already registered.
+	if !errors.As(reg.Register(metrics.lookups), &prometheus.AlreadyRegisteredError{}) {
+		t.Fatalf("expected RegisterMetrics to register at least one collector")
+	}
+}
+
+func TestLookupConsumerRecordsHitAndMiss(t *testing.T) {
+	c := &Consumer{ConsumerName: "c1"}
+	currentIndex.Store(&Index{
+		resource: map[string]map[string]*Consumer{},
+		scope: map[string]map[string]map[string]*Consumer{
+			"metrics-lookup-ns": {"plugin": {"key": c}},
+		},
+	})
+
+	beforeHit := testutil.ToFloat64(metrics.lookups.WithLabelValues("metrics-lookup-ns", "plugin", resultHit))
+	if _, ok := LookupConsumer("metrics-lookup-ns", "plugin", "key"); !ok {
+		t.Fatalf("expected a hit")
+	}
+	afterHit := testutil.ToFloat64(metrics.lookups.WithLabelValues("metrics-lookup-ns", "plugin", resultHit))
+	if afterHit != beforeHit+1 {
+		t.Fatalf("expected hit counter to increase by 1, got %v -> %v", beforeHit, afterHit)
+	}
+
+	beforeMiss := testutil.ToFloat64(metrics.lookups.WithLabelValues("metrics-lookup-ns", "plugin", resultMiss))
+	if _, ok := LookupConsumer("metrics-lookup-ns", "plugin", "missing"); ok {
+		t.Fatalf("expected a miss")
+	}
+	afterMiss := testutil.ToFloat64(metrics.lookups.WithLabelValues("metrics-lookup-ns", "plugin", resultMiss))
+	if afterMiss != beforeMiss+1 {
+		t.Fatalf("expected miss counter to increase by 1, got %v -> %v", beforeMiss, afterMiss)
+	}
+}
+
+func TestUpdateConsumersRecordsUnmarshalErrorAsUpdateFailure(t *testing.T) {
+	const ns = "metrics-unmarshal-error-ns"
+
+	s, err := structpb.NewStruct(map[string]interface{}{
+		ns: map[string]interface{}{
+			"bad-consumer": map[string]interface{}{
+				"v": "v1",
+				"d": "{not valid json",
+			},
+		},
+	})
+	if err != nil {
+		t.Fatalf("failed to build test struct: %v", err)
+	}
+
+	before := testutil.ToFloat64(metrics.updates.WithLabelValues(ns, resultError))
+	updateConsumers(s)
+	after := testutil.ToFloat64(metrics.updates.WithLabelValues(ns, resultError))
+	if after != before+1 {
+		t.Fatalf("expected the error counter to increase by 1 on an unmarshal failure, got %v -> %v", before, after)
+	}
+}
+
+func TestUpdateConsumersRecordsUpdatesAndConsumerCount(t *testing.T) {
+	const ns = "metrics-update-ns"
+
+	beforeSuccess := testutil.ToFloat64(metrics.updates.WithLabelValues(ns, resultSuccess))
+	updateConsumers(buildConsumersStruct(ns, 2, "v1"))
+	afterSuccess := testutil.ToFloat64(metrics.updates.WithLabelValues(ns, resultSuccess))
+	if afterSuccess != beforeSuccess+2 {
+		t.Fatalf("expected 2 successful updates, got %v -> %v", beforeSuccess, afterSuccess)
+	}
+
+	count := testutil.ToFloat64(metrics.consumerCount.WithLabelValues(ns))
+	if count != 2 {
+		t.Fatalf("expected consumer count gauge to read 2, got %v", count)
+	}
+}
+
+func TestBuildScopeIndexBumpsDuplicateIndexCounter(t *testing.T) {
+	const ns = "metrics-duplicate-ns"
+	const plugin = "fake_plugin"
+
+	before := testutil.ToFloat64(metrics.duplicateIndex.WithLabelValues(ns, plugin))
+
+	resource := map[string]map[string]*Consumer{
+		ns: {
+			"c1": consumerWithConfig("c1", plugin, newFakeConsumerConfig("same-key")),
+			"c2": consumerWithConfig("c2", plugin, newFakeConsumerConfig("same-key")),
+		},
+	}
+
+	scope := buildScopeIndex(resource)
+
+	after := testutil.ToFloat64(metrics.duplicateIndex.WithLabelValues(ns, plugin))
+	if after != before+1 {
+		t.Fatalf("expected the duplicate index counter to increase by 1, got %v -> %v", before, after)
+	}
+
+	if len(scope[ns][plugin]) != 1 {
+		t.Fatalf("expected only the first consumer to win the collision, got %d entries", len(scope[ns][plugin]))
+	}
+}